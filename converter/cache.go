@@ -0,0 +1,345 @@
+package main
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// pHash is a 64-bit perceptual hash computed over a source image's
+// low-frequency DCT coefficients. Visually similar images hash to values a
+// small Hamming distance apart, which is what lets ImageCache collapse
+// duplicate uploads under different S3 keys into one cache entry.
+type pHash uint64
+
+// computePerceptualHash implements the standard pHash recipe: shrink to
+// 32x32 so only low frequencies survive, grayscale, run a 2D DCT, keep the
+// top-left 8x8 block of coefficients, and threshold each one against the
+// median of the 63 AC coefficients (the DC term at [0][0] is excluded from
+// the median so a handful of very bright/dark images don't skew it).
+func computePerceptualHash(img image.Image) pHash {
+	small := imaging.Resize(img, 32, 32, imaging.Lanczos)
+	gray := imaging.Grayscale(small)
+
+	const n = 32
+	pixels := make([][]float64, n)
+	for y := 0; y < n; y++ {
+		pixels[y] = make([]float64, n)
+		for x := 0; x < n; x++ {
+			r, _, _, _ := gray.At(x, y).RGBA()
+			pixels[y][x] = float64(r >> 8)
+		}
+	}
+
+	freq := dct2D(pixels)
+
+	var coeffs [64]float64
+	i := 0
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			coeffs[i] = freq[y][x]
+			i++
+		}
+	}
+
+	median := medianExcludingDC(coeffs)
+
+	var hash pHash
+	for i, v := range coeffs {
+		if v > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+func medianExcludingDC(coeffs [64]float64) float64 {
+	vals := make([]float64, 0, 63)
+	for i, v := range coeffs {
+		if i == 0 {
+			continue
+		}
+		vals = append(vals, v)
+	}
+	sort.Float64s(vals)
+	mid := len(vals) / 2
+	if len(vals)%2 == 0 {
+		return (vals[mid-1] + vals[mid]) / 2
+	}
+	return vals[mid]
+}
+
+// dct2D runs a separable 2D DCT-II over a square matrix: 1D DCT over rows,
+// then 1D DCT over the resulting columns.
+func dct2D(pixels [][]float64) [][]float64 {
+	n := len(pixels)
+
+	rows := make([][]float64, n)
+	for y := 0; y < n; y++ {
+		rows[y] = dct1D(pixels[y])
+	}
+
+	out := make([][]float64, n)
+	for y := 0; y < n; y++ {
+		out[y] = make([]float64, n)
+	}
+	col := make([]float64, n)
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			col[y] = rows[y][x]
+		}
+		res := dct1D(col)
+		for y := 0; y < n; y++ {
+			out[y][x] = res[y]
+		}
+	}
+	return out
+}
+
+func dct1D(in []float64) []float64 {
+	n := len(in)
+	out := make([]float64, n)
+	for k := 0; k < n; k++ {
+		var sum float64
+		for i, v := range in {
+			sum += v * math.Cos(math.Pi/float64(n)*(float64(i)+0.5)*float64(k))
+		}
+		scale := math.Sqrt(2.0 / float64(n))
+		if k == 0 {
+			scale = math.Sqrt(1.0 / float64(n))
+		}
+		out[k] = sum * scale
+	}
+	return out
+}
+
+func hammingDistance(a, b pHash) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// cacheParams is the normalized set of conversion parameters a cache entry
+// is keyed on, alongside the source's perceptual hash.
+func cacheParams(width, height *uint32, quality int, fit FitMode, format OutputFormat) string {
+	return fmt.Sprintf("w=%v;h=%v;q=%d;fit=%s;fmt=%d", derefU32(width), derefU32(height), quality, fit, format)
+}
+
+func derefU32(v *uint32) uint32 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+type cacheEntry struct {
+	hash        pHash
+	params      string
+	data        []byte
+	contentType string
+	storedAt    time.Time
+}
+
+type CacheStats struct {
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// ImageCache is a two-tier cache of converted image bytes keyed by
+// (perceptual hash, conversion params): an in-memory LRU, backed
+// optionally by an on-disk directory that every stored entry is also
+// written to (eviction from memory does not touch it). Lookups within a params bucket are a linear scan comparing
+// Hamming distance, since near-duplicate matching can't be done with a
+// plain map.
+type ImageCache struct {
+	mu      sync.Mutex
+	maxSize int
+	ttl     time.Duration
+	hamming int
+	dir     string
+
+	order   *list.List // front = most recently used, elements are *cacheEntry
+	byBucket map[string][]*list.Element
+
+	hits   uint64
+	misses uint64
+}
+
+func NewImageCache(cfg *Config) *ImageCache {
+	return &ImageCache{
+		maxSize:  cfg.CacheMaxEntries,
+		ttl:      cfg.CacheTTL,
+		hamming:  cfg.CacheHammingThreshold,
+		dir:      cfg.CacheDir,
+		order:    list.New(),
+		byBucket: make(map[string][]*list.Element),
+	}
+}
+
+// Lookup returns cached bytes for a source hashing to `hash` under the given
+// params, accepting any previously-cached entry within the configured
+// Hamming distance threshold.
+func (c *ImageCache) Lookup(hash pHash, params string) (data []byte, contentType string, ok bool) {
+	c.mu.Lock()
+	for _, el := range c.byBucket[params] {
+		entry := el.Value.(*cacheEntry)
+		if c.ttl > 0 && time.Since(entry.storedAt) > c.ttl {
+			continue
+		}
+		if hammingDistance(hash, entry.hash) <= c.hamming {
+			c.order.MoveToFront(el)
+			c.hits++
+			data, contentType = entry.data, entry.contentType
+			c.mu.Unlock()
+			return data, contentType, true
+		}
+	}
+	c.mu.Unlock()
+
+	// Disk-hit promotion happens outside the lock: Store acquires c.mu
+	// itself, and sync.Mutex isn't reentrant.
+	if data, contentType, ok := c.lookupDisk(hash, params); ok {
+		c.mu.Lock()
+		c.hits++
+		c.mu.Unlock()
+		c.Store(hash, params, data, contentType)
+		return data, contentType, true
+	}
+
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+	return nil, "", false
+}
+
+// Store records a conversion result for future Lookups, evicting the
+// least-recently-used entry if the cache is at capacity.
+func (c *ImageCache) Store(hash pHash, params string, data []byte, contentType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{hash: hash, params: params, data: data, contentType: contentType, storedAt: time.Now()}
+	el := c.order.PushFront(entry)
+	c.byBucket[params] = append(c.byBucket[params], el)
+
+	if c.maxSize > 0 && c.order.Len() > c.maxSize {
+		c.evictOldest()
+	}
+
+	c.writeDisk(entry)
+}
+
+func (c *ImageCache) evictOldest() {
+	oldest := c.order.Back()
+	if oldest == nil {
+		return
+	}
+	entry := oldest.Value.(*cacheEntry)
+	c.order.Remove(oldest)
+
+	bucket := c.byBucket[entry.params]
+	for i, el := range bucket {
+		if el == oldest {
+			c.byBucket[entry.params] = append(bucket[:i], bucket[i+1:]...)
+			break
+		}
+	}
+}
+
+func (c *ImageCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Entries: c.order.Len(),
+		Hits:    c.hits,
+		Misses:  c.misses,
+	}
+}
+
+// diskPath returns the write-back path for a cache entry: one subdirectory
+// per params bucket (so a disk-side near-duplicate scan only needs to list
+// that bucket), file named after the hash.
+func (c *ImageCache) diskPath(hash pHash, params string) string {
+	bucketHash := fnv.New32a()
+	bucketHash.Write([]byte(params))
+	bucket := fmt.Sprintf("%08x", bucketHash.Sum32())
+	return filepath.Join(c.dir, bucket, fmt.Sprintf("%016x.cache", uint64(hash)))
+}
+
+// diskEntryHeaderSize is the size of the storedAt timestamp (unix nanos,
+// big-endian) prefixed to every on-disk cache file, so CacheTTL can be
+// enforced on the disk tier the same as the in-memory one.
+const diskEntryHeaderSize = 8
+
+func (c *ImageCache) writeDisk(entry *cacheEntry) {
+	if c.dir == "" {
+		return
+	}
+	path := c.diskPath(entry.hash, entry.params)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	buf := make([]byte, diskEntryHeaderSize+len(entry.data))
+	binary.BigEndian.PutUint64(buf[:diskEntryHeaderSize], uint64(entry.storedAt.UnixNano()))
+	copy(buf[diskEntryHeaderSize:], entry.data)
+	_ = os.WriteFile(path, buf, 0o644)
+}
+
+func (c *ImageCache) lookupDisk(hash pHash, params string) ([]byte, string, bool) {
+	if c.dir == "" {
+		return nil, "", false
+	}
+	bucketHash := fnv.New32a()
+	bucketHash.Write([]byte(params))
+	bucketDir := filepath.Join(c.dir, fmt.Sprintf("%08x", bucketHash.Sum32()))
+
+	entries, err := os.ReadDir(bucketDir)
+	if err != nil {
+		return nil, "", false
+	}
+	for _, e := range entries {
+		var stored uint64
+		if _, err := fmt.Sscanf(e.Name(), "%016x.cache", &stored); err != nil {
+			continue
+		}
+		if hammingDistance(hash, pHash(stored)) > c.hamming {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(bucketDir, e.Name()))
+		if err != nil || len(raw) < diskEntryHeaderSize {
+			continue
+		}
+		storedAt := time.Unix(0, int64(binary.BigEndian.Uint64(raw[:diskEntryHeaderSize])))
+		if c.ttl > 0 && time.Since(storedAt) > c.ttl {
+			continue
+		}
+		return raw[diskEntryHeaderSize:], contentTypeFromParams(params), true
+	}
+	return nil, "", false
+}
+
+// contentTypeFromParams recovers the content type encoded in a cacheParams
+// string, since the disk tier only stores raw bytes under that key.
+func contentTypeFromParams(params string) string {
+	idx := strings.LastIndex(params, "fmt=")
+	if idx == -1 {
+		return "application/octet-stream"
+	}
+	format, err := strconv.Atoi(params[idx+len("fmt="):])
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return outputContentType(OutputFormat(format))
+}
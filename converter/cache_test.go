@@ -0,0 +1,75 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func solidImage(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func checkerboardImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x/4+y/4)%2 == 0 {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	return img
+}
+
+func TestComputePerceptualHashStableForIdenticalImages(t *testing.T) {
+	img := checkerboardImage(64, 64)
+	a := computePerceptualHash(img)
+	b := computePerceptualHash(img)
+	if a != b {
+		t.Fatalf("computePerceptualHash() not stable: %x != %x", a, b)
+	}
+}
+
+func TestComputePerceptualHashDistinguishesDifferentImages(t *testing.T) {
+	solid := computePerceptualHash(solidImage(64, 64, color.White))
+	checker := computePerceptualHash(checkerboardImage(64, 64))
+
+	if hammingDistance(solid, checker) == 0 {
+		t.Fatal("expected visually distinct images to hash to different pHashes")
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	tests := []struct {
+		a, b pHash
+		want int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0, 0xFF, 8},
+		{0xFFFFFFFFFFFFFFFF, 0, 64},
+	}
+	for _, tt := range tests {
+		if got := hammingDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("hammingDistance(%x, %x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCacheParamsDistinguishesInputs(t *testing.T) {
+	w := uint32(100)
+	p1 := cacheParams(&w, nil, 80, FitModeCover, OutputFormatWebP)
+	p2 := cacheParams(&w, nil, 90, FitModeCover, OutputFormatWebP)
+	if p1 == p2 {
+		t.Fatal("cacheParams() should differ when quality differs")
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -13,6 +15,52 @@ type Config struct {
 	S3Region             string
 	WebPDefaultQuality   int
 	MaxDimension         uint32
+
+	// Video/animated-frame extraction limits. See extractVideoFrame in
+	// video.go. MaxVideoSourceBytes bounds how large a source object ffmpeg
+	// is asked to decode; VideoFrameTimeout bounds how long it's allowed to
+	// run.
+	MaxVideoSourceBytes int64
+	VideoFrameTimeout   time.Duration
+
+	// Perceptual-hash cache settings. See cache.go.
+	CacheEnabled          bool
+	CacheMaxEntries       int
+	CacheTTL              time.Duration
+	CacheHammingThreshold int
+	CacheDir              string
+
+	// Signed URL settings. See signer package.
+	SigningSecret     string
+	RequireSignedURLs bool
+
+	// Storage backend selection. StorageBackend is one of "s3" (default),
+	// "filesystem", "webdav", or "http". Sources maps a path's first
+	// segment to a backend-specific location (an S3 bucket, a filesystem
+	// subdirectory, a WebDAV path prefix, ...); when empty, the path
+	// segment itself is used as the location, preserving the original
+	// /{bucket}/{key} behavior. See storage.go and resolveSource in
+	// handlers.go.
+	StorageBackend string
+	Sources        map[string]string
+
+	FilesystemRoot string
+
+	WebDAVBaseURL  string
+	WebDAVUsername string
+	WebDAVPassword string
+
+	HTTPOriginTemplate string
+
+	// RequestReproducerDir enables dumping failing requests to disk for
+	// offline replay against Convert. Empty disables it. See reproducer.go.
+	RequestReproducerDir string
+
+	// PreferredFormats breaks ties when an Accept header accepts multiple
+	// output formats with the same q-value, e.g. preferring AVIF over WebP
+	// when a client accepts both unconditionally. See determineFormat in
+	// negotiate.go.
+	PreferredFormats []OutputFormat
 }
 
 func LoadConfig() *Config {
@@ -24,6 +72,34 @@ func LoadConfig() *Config {
 		S3Region:             getEnv("S3_REGION", "us-east-1"),
 		WebPDefaultQuality:   getEnvInt("WEBP_DEFAULT_QUALITY", 85),
 		MaxDimension:         uint32(getEnvInt("MAX_DIMENSION", 4096)),
+
+		MaxVideoSourceBytes: int64(getEnvInt("MAX_VIDEO_SOURCE_BYTES", 200*1024*1024)),
+		VideoFrameTimeout:   getEnvDuration("VIDEO_FRAME_TIMEOUT", 15*time.Second),
+
+		CacheEnabled:          getEnvBool("CACHE_ENABLED", false),
+		CacheMaxEntries:       getEnvInt("CACHE_MAX_ENTRIES", 1024),
+		CacheTTL:              getEnvDuration("CACHE_TTL", time.Hour),
+		CacheHammingThreshold: getEnvInt("CACHE_HAMMING_THRESHOLD", 4),
+		CacheDir:              getEnv("CACHE_DIR", ""),
+
+		SigningSecret:     getEnv("SIGNING_SECRET", ""),
+		RequireSignedURLs: getEnvBool("REQUIRE_SIGNED_URLS", false),
+
+		StorageBackend: getEnv("STORAGE_BACKEND", "s3"),
+		Sources:        getEnvMap("SOURCES", nil),
+
+		FilesystemRoot: getEnv("FILESYSTEM_ROOT", "."),
+
+		WebDAVBaseURL:  getEnv("WEBDAV_BASE_URL", ""),
+		WebDAVUsername: getEnv("WEBDAV_USERNAME", ""),
+		WebDAVPassword: getEnv("WEBDAV_PASSWORD", ""),
+
+		HTTPOriginTemplate: getEnv("HTTP_ORIGIN_TEMPLATE", ""),
+
+		RequestReproducerDir: getEnv("REQUEST_REPRODUCER_DIR", ""),
+
+		PreferredFormats: getEnvFormatList("PREFERRED_FORMATS",
+			[]OutputFormat{OutputFormatAVIF, OutputFormatWebP, OutputFormatJPEG, OutputFormatPNG}),
 	}
 }
 
@@ -42,3 +118,61 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if durValue, err := time.ParseDuration(value); err == nil {
+			return durValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvFormatList parses a comma-separated list of format names (e.g.
+// "avif,webp,jpeg,png") into an ordered []OutputFormat, used for
+// Config.PreferredFormats. Unknown names are skipped.
+func getEnvFormatList(key string, defaultValue []OutputFormat) []OutputFormat {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var result []OutputFormat
+	for _, name := range strings.Split(value, ",") {
+		if format, ok := ParseOutputFormat(strings.TrimSpace(name)); ok {
+			result = append(result, format)
+		}
+	}
+	if len(result) == 0 {
+		return defaultValue
+	}
+	return result
+}
+
+// getEnvMap parses a "name1=value1,name2=value2" environment variable into
+// a map, used for Config.Sources.
+func getEnvMap(key string, defaultValue map[string]string) map[string]string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		name, location, found := strings.Cut(pair, "=")
+		if !found {
+			continue
+		}
+		result[name] = location
+	}
+	return result
+}
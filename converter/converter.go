@@ -2,11 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
 	"image/jpeg"
 	_ "image/gif"
 	_ "image/png"
+	"strings"
+	"time"
 
 	"github.com/chai2010/webp"
 	"github.com/disintegration/imaging"
@@ -22,6 +25,41 @@ const (
 	OutputFormatJPEG
 )
 
+// String returns the canonical image/* MIME subtype for the format; these are
+// also names ParseOutputFormat accepts.
+func (f OutputFormat) String() string {
+	switch f {
+	case OutputFormatAVIF:
+		return "avif"
+	case OutputFormatWebP:
+		return "webp"
+	case OutputFormatJPEG:
+		return "jpeg"
+	case OutputFormatPNG:
+		return "png"
+	default:
+		return "png"
+	}
+}
+
+// ParseOutputFormat parses a format name such as "avif", "webp", "jpeg" or
+// "png" (case-insensitive) into an OutputFormat. ok is false for unknown
+// names.
+func ParseOutputFormat(s string) (format OutputFormat, ok bool) {
+	switch strings.ToLower(s) {
+	case "avif":
+		return OutputFormatAVIF, true
+	case "webp":
+		return OutputFormatWebP, true
+	case "jpeg", "jpg":
+		return OutputFormatJPEG, true
+	case "png":
+		return OutputFormatPNG, true
+	default:
+		return OutputFormatPNG, false
+	}
+}
+
 type FitMode int
 
 const (
@@ -63,6 +101,17 @@ type ConversionRequest struct {
 	OutputFormat OutputFormat
 	Quality      int
 	FitMode      FitMode
+	// FrameTime selects the timestamp to extract a still from when Data is a
+	// video container. Defaults to the first frame (0s) when nil.
+	FrameTime *time.Duration
+	// FrameIndex selects a frame of an animated GIF/WebP source. Defaults to
+	// the first frame (0) when nil.
+	FrameIndex *int
+	// MaxVideoBytes and VideoTimeout bound video-frame extraction (see
+	// extractVideoFrame in video.go); zero values disable the corresponding
+	// check.
+	MaxVideoBytes int64
+	VideoTimeout  time.Duration
 }
 
 type ConversionResult struct {
@@ -72,30 +121,43 @@ type ConversionResult struct {
 	OriginalHeight uint32
 	OutputWidth    uint32
 	OutputHeight   uint32
+	InputKind      InputKind
+
+	// Per-stage timings, surfaced in structured request logs.
+	DecodeDuration time.Duration
+	ResizeDuration time.Duration
+	EncodeDuration time.Duration
 }
 
-func Convert(req *ConversionRequest) (*ConversionResult, error) {
-	// Decode image
-	img, _, err := image.Decode(bytes.NewReader(req.Data))
+func Convert(ctx context.Context, req *ConversionRequest) (*ConversionResult, error) {
+	inputKind := detectInputKind(req.Data)
+
+	decodeStart := time.Now()
+	img, err := decodeSource(ctx, req, inputKind)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+		return nil, err
 	}
+	decodeDuration := time.Since(decodeStart)
 
 	bounds := img.Bounds()
 	originalWidth := uint32(bounds.Dx())
 	originalHeight := uint32(bounds.Dy())
 
 	// Resize if dimensions are specified
+	resizeStart := time.Now()
 	resized := resizeImage(img, req.Width, req.Height, req.FitMode)
+	resizeDuration := time.Since(resizeStart)
 	resizedBounds := resized.Bounds()
 	outputWidth := uint32(resizedBounds.Dx())
 	outputHeight := uint32(resizedBounds.Dy())
 
 	// Encode to output format
+	encodeStart := time.Now()
 	data, contentType, err := encodeImage(resized, req.OutputFormat, req.Quality)
 	if err != nil {
 		return nil, err
 	}
+	encodeDuration := time.Since(encodeStart)
 
 	return &ConversionResult{
 		Data:           data,
@@ -104,9 +166,52 @@ func Convert(req *ConversionRequest) (*ConversionResult, error) {
 		OriginalHeight: originalHeight,
 		OutputWidth:    outputWidth,
 		OutputHeight:   outputHeight,
+		InputKind:      inputKind,
+		DecodeDuration: decodeDuration,
+		ResizeDuration: resizeDuration,
+		EncodeDuration: encodeDuration,
 	}, nil
 }
 
+// decodeSource turns the raw source bytes into an image.Image, taking the
+// detected InputKind into account: video containers are reduced to a still
+// frame via ffmpeg, and animated images are reduced to a single frame.
+func decodeSource(ctx context.Context, req *ConversionRequest, kind InputKind) (image.Image, error) {
+	switch kind {
+	case InputKindVideo:
+		frameTime := time.Duration(0)
+		if req.FrameTime != nil {
+			frameTime = *req.FrameTime
+		}
+		frame, err := extractVideoFrame(ctx, req.Data, frameTime, req.MaxVideoBytes, req.VideoTimeout)
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(bytes.NewReader(frame))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode extracted video frame: %w", err)
+		}
+		return img, nil
+
+	case InputKindAnimated:
+		frameIndex := 0
+		if req.FrameIndex != nil {
+			frameIndex = *req.FrameIndex
+		}
+		if bytes.HasPrefix(req.Data, []byte("GIF8")) {
+			return extractGIFFrame(req.Data, frameIndex)
+		}
+		return extractWebPFrame(req.Data, frameIndex)
+
+	default:
+		img, _, err := image.Decode(bytes.NewReader(req.Data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		return img, nil
+	}
+}
+
 func resizeImage(img image.Image, width, height *uint32, fitMode FitMode) image.Image {
 	if width == nil && height == nil {
 		return img
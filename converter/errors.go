@@ -1,6 +1,9 @@
 package main
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type NotFoundError struct {
 	Path string
@@ -9,3 +12,10 @@ type NotFoundError struct {
 func (e *NotFoundError) Error() string {
 	return fmt.Sprintf("image not found: %s", e.Path)
 }
+
+// Range errors returned by S3Storage.GetObjectRange and surfaced by
+// ImageHandler as HTTP 416 responses.
+var (
+	ErrInvalidRangeLength = errors.New("invalid range length")
+	ErrRangeOutOfBounds   = errors.New("range out of bounds")
+)
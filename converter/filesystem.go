@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// FilesystemStorage serves objects from a local directory tree, mainly for
+// local development and tests. bucket is joined under Root as a
+// subdirectory, so /{bucket}/{key} maps to Root/bucket/key on disk.
+type FilesystemStorage struct {
+	Root string
+}
+
+func NewFilesystemStorage(cfg *Config) *FilesystemStorage {
+	return &FilesystemStorage{Root: cfg.FilesystemRoot}
+}
+
+func (f *FilesystemStorage) resolve(bucket, key string) string {
+	return filepath.Join(f.Root, filepath.FromSlash(bucket), filepath.FromSlash(key))
+}
+
+func (f *FilesystemStorage) GetObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	path := f.resolve(bucket, key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
+		}
+		return nil, fmt.Errorf("filesystem error: %w", err)
+	}
+	return data, nil
+}
+
+func (f *FilesystemStorage) GetObjectRange(ctx context.Context, bucket, key string, start, end int64) ([]byte, int64, error) {
+	path := f.resolve(bucket, key)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
+		}
+		return nil, 0, fmt.Errorf("filesystem error: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("filesystem error: %w", err)
+	}
+	total := info.Size()
+
+	resolvedStart, resolvedEnd, err := resolveFileRange(start, end, total)
+	if err != nil {
+		return nil, total, err
+	}
+
+	if _, err := file.Seek(resolvedStart, io.SeekStart); err != nil {
+		return nil, total, fmt.Errorf("filesystem error: %w", err)
+	}
+	data := make([]byte, resolvedEnd-resolvedStart+1)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, total, fmt.Errorf("filesystem error: %w", err)
+	}
+	return data, total, nil
+}
+
+// resolveFileRange applies the same start/end sentinel convention as
+// S3Storage.GetObjectRange (see s3.go) against a known total size.
+func resolveFileRange(start, end, total int64) (int64, int64, error) {
+	switch {
+	case start >= 0 && end >= 0:
+		if end < start || start >= total {
+			return 0, 0, ErrRangeOutOfBounds
+		}
+		if end >= total {
+			end = total - 1
+		}
+		return start, end, nil
+	case start >= 0 && end < 0:
+		if start >= total {
+			return 0, 0, ErrRangeOutOfBounds
+		}
+		return start, total - 1, nil
+	case start < 0 && end >= 0:
+		length := end
+		if length > total {
+			length = total
+		}
+		return total - length, total - 1, nil
+	default:
+		return 0, 0, ErrInvalidRangeLength
+	}
+}
+
+func (f *FilesystemStorage) HeadObject(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	path := f.resolve(bucket, key)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
+		}
+		return nil, fmt.Errorf("filesystem error: %w", err)
+	}
+	return &ObjectInfo{
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(filepath.Ext(key)),
+	}, nil
+}
+
+func (f *FilesystemStorage) PutObject(ctx context.Context, bucket, key string, data []byte) error {
+	path := f.resolve(bucket, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("filesystem error: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("filesystem error: %w", err)
+	}
+	return nil
+}
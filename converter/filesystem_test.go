@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFilesystemStorageResolve(t *testing.T) {
+	f := &FilesystemStorage{Root: "/data"}
+	got := f.resolve("mybucket", "images/cat.png")
+	want := filepath.Join("/data", "mybucket", "images", "cat.png")
+	if got != want {
+		t.Errorf("resolve() = %q, want %q", got, want)
+	}
+}
+
+func TestResolveFileRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int64
+		total      int64
+		wantStart  int64
+		wantEnd    int64
+		wantErr    error
+	}{
+		{"explicit range", 0, 499, 1000, 0, 499, nil},
+		{"explicit range clamped to total", 500, 2000, 1000, 500, 999, nil},
+		{"start to end of file", 500, -1, 1000, 500, 999, nil},
+		{"suffix range", -1, 500, 1000, 500, 999, nil},
+		{"suffix range longer than total", -1, 2000, 1000, 0, 999, nil},
+		{"start past total", 1000, -1, 1000, 0, 0, ErrRangeOutOfBounds},
+		{"end before start", 500, 100, 1000, 0, 0, ErrRangeOutOfBounds},
+		{"neither bound set", -1, -1, 1000, 0, 0, ErrInvalidRangeLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := resolveFileRange(tt.start, tt.end, tt.total)
+			if err != tt.wantErr {
+				t.Fatalf("resolveFileRange(%d, %d, %d) error = %v, want %v", tt.start, tt.end, tt.total, err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("resolveFileRange(%d, %d, %d) = (%d, %d), want (%d, %d)",
+					tt.start, tt.end, tt.total, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
@@ -1,16 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"image"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/windyakin/soeji/signer"
 )
 
 type AppState struct {
-	Config   *Config
-	S3Client *S3Client
+	Config     *Config
+	Storage    Storage
+	Cache      *ImageCache
+	Signer     *signer.Signer
+	Reproducer *RequestReproducer
 }
 
 func (s *AppState) RootHandler(w http.ResponseWriter, r *http.Request) {
@@ -22,19 +31,46 @@ func (s *AppState) RootHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *AppState) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Cache == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	stats := s.Cache.Stats()
+	fmt.Fprintf(w, `{"status":"ok","cache":{"entries":%d,"hits":%d,"misses":%d}}`,
+		stats.Entries, stats.Hits, stats.Misses)
 }
 
 func (s *AppState) ImageHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	reqID := requestID(r)
+	ctx := withRequestID(r.Context(), reqID)
+	w.Header().Set("X-Request-Id", reqID)
 
-	// Parse path: /{bucket}/{key}
-	bucket, key, err := parsePath(r.URL.Path)
+	// Parse path: /{source}/{key}
+	source, key, err := parsePath(r.URL.Path)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Verify the signature before resolveSource, so an unauthenticated
+	// client can't use a 404-vs-403 distinction to enumerate configured
+	// source names.
+	if s.Signer != nil {
+		if err := s.Signer.Verify(r.URL.Path, r.URL.Query()); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	bucket, err := resolveSource(s.Config, source)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
 	// Parse query parameters
 	query := r.URL.Query()
 
@@ -82,41 +118,141 @@ func (s *AppState) ImageHandler(w http.ResponseWriter, r *http.Request) {
 		fitMode = ParseFitMode(fit)
 	}
 
-	// Determine output format from Accept header
-	outputFormat := determineFormat(r.Header.Get("Accept"))
+	var frameTime *time.Duration
+	if t := query.Get("t"); t != "" {
+		val, err := time.ParseDuration(t)
+		if err != nil || val < 0 {
+			http.Error(w, "invalid t parameter", http.StatusBadRequest)
+			return
+		}
+		frameTime = &val
+	}
+
+	var frameIndex *int
+	if frame := query.Get("frame"); frame != "" {
+		val, err := strconv.Atoi(frame)
+		if err != nil || val < 0 {
+			http.Error(w, "invalid frame parameter", http.StatusBadRequest)
+			return
+		}
+		frameIndex = &val
+	}
+
+	// Determine output format: an explicit ?format= override takes
+	// precedence over Accept-header negotiation.
+	var outputFormat OutputFormat
+	if formatParam := query.Get("format"); formatParam != "" {
+		parsed, ok := ParseOutputFormat(formatParam)
+		if !ok {
+			http.Error(w, "invalid format parameter", http.StatusBadRequest)
+			return
+		}
+		outputFormat = parsed
+	} else {
+		outputFormat = determineFormat(r.Header.Get("Accept"), s.Config.PreferredFormats)
+	}
+
+	// When no resize is needed and the requested output format matches the
+	// source object's own format, a Range request can be served directly
+	// from storage without decoding the whole object. Video/container
+	// sources have no OutputFormat equivalent to match against, but when no
+	// image transform (resize, explicit frame) is requested either, the raw
+	// container bytes are exactly what a client asking for a video byte
+	// range wants - that's what lets raw video passthrough work from a CDN.
+	sourceContentType := contentTypeForKey(key)
+	noTransformRequested := width == nil && height == nil && frameTime == nil && frameIndex == nil
+	passthroughEligible := noTransformRequested && sourceContentType != "" &&
+		(sourceContentType == outputContentType(outputFormat) || isContainerContentType(sourceContentType))
+
+	if passthroughEligible {
+		w.Header().Set("Accept-Ranges", "bytes")
+	}
+
+	rl := newRequestLog(reqID, bucket, key)
 
-	// Fetch image from S3
-	log.Printf("Fetching image from S3: bucket=%s, key=%s", bucket, key)
-	data, err := s.S3Client.GetObject(ctx, bucket, key)
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && passthroughEligible {
+		s.serveRange(w, ctx, r, rl, bucket, key, sourceContentType, rangeHeader)
+		return
+	}
+
+	rl.Quality = quality
+	rl.FitMode = fitMode.String()
+
+	// Fetch image from storage
+	fetchStart := time.Now()
+	data, err := s.Storage.GetObject(ctx, bucket, key)
+	rl.FetchDuration = time.Since(fetchStart)
 	if err != nil {
 		if _, ok := err.(*NotFoundError); ok {
 			http.Error(w, "Image not found", http.StatusNotFound)
 			return
 		}
-		log.Printf("S3 error: %v", err)
+		rl.Err = err
+		rl.emit()
+		s.Reproducer.Dump(reqID, r, nil)
 		http.Error(w, "Failed to fetch image from storage", http.StatusBadGateway)
 		return
 	}
+	rl.SourceBytes = len(data)
+
+	// Check the perceptual-hash cache before paying for a resize/encode.
+	// Only plain (non-animated, non-video) sources are cache-eligible. The
+	// source is decoded here for hashing, and decoded again by Convert on a
+	// cache miss.
+	var cacheHash pHash
+	cacheParamsKey := cacheParams(width, height, quality, fitMode, outputFormat)
+	cacheable := s.Cache != nil && detectInputKind(data) == InputKindImage
+	if cacheable {
+		if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+			cacheHash = computePerceptualHash(img)
+			if cachedData, cachedContentType, ok := s.Cache.Lookup(cacheHash, cacheParamsKey); ok {
+				rl.CacheHit = true
+				rl.emit()
+				w.Header().Set("Content-Type", cachedContentType)
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+				w.Header().Set("Vary", "Accept")
+				w.Header().Set("X-Cache", "HIT")
+				w.WriteHeader(http.StatusOK)
+				w.Write(cachedData)
+				return
+			}
+		}
+	}
 
 	// Convert image
-	log.Printf("Converting image: w=%v, h=%v, q=%d, format=%d", width, height, quality, outputFormat)
-	result, err := Convert(&ConversionRequest{
-		Data:         data,
-		Width:        width,
-		Height:       height,
-		OutputFormat: outputFormat,
-		Quality:      quality,
-		FitMode:      fitMode,
+	result, err := Convert(ctx, &ConversionRequest{
+		Data:          data,
+		Width:         width,
+		Height:        height,
+		OutputFormat:  outputFormat,
+		Quality:       quality,
+		FitMode:       fitMode,
+		FrameTime:     frameTime,
+		FrameIndex:    frameIndex,
+		MaxVideoBytes: s.Config.MaxVideoSourceBytes,
+		VideoTimeout:  s.Config.VideoFrameTimeout,
 	})
 	if err != nil {
-		log.Printf("Image processing error: %v", err)
+		rl.Err = err
+		rl.emit()
+		s.Reproducer.Dump(reqID, r, data)
 		http.Error(w, "Failed to process image", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Conversion complete: %dx%d -> %dx%d",
-		result.OriginalWidth, result.OriginalHeight,
-		result.OutputWidth, result.OutputHeight)
+	rl.DecodedWidth = result.OriginalWidth
+	rl.DecodedHeight = result.OriginalHeight
+	rl.OutputWidth = result.OutputWidth
+	rl.OutputHeight = result.OutputHeight
+	rl.OutputFormat = result.ContentType
+	rl.DecodeDuration = result.DecodeDuration
+	rl.ResizeDuration = result.ResizeDuration
+	rl.EncodeDuration = result.EncodeDuration
+	rl.emit()
+
+	if cacheable {
+		s.Cache.Store(cacheHash, cacheParamsKey, result.Data, result.ContentType)
+	}
 
 	// Set response headers
 	w.Header().Set("Content-Type", result.ContentType)
@@ -126,30 +262,97 @@ func (s *AppState) ImageHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(result.Data)
 }
 
-func parsePath(path string) (bucket, key string, err error) {
+// serveRange handles a single-range HTTP Range request by fetching only the
+// requested bytes from storage and writing a 206 Partial Content response.
+// Like the rest of ImageHandler, it reports through the structured
+// requestLog rather than the stdlib logger, both on the success path and
+// for unexpected (non-client-error) storage failures.
+func (s *AppState) serveRange(w http.ResponseWriter, ctx context.Context, r *http.Request, rl *requestLog, bucket, key, contentType, rangeHeader string) {
+	parsed, err := parseRangeHeader(rangeHeader)
+	if err != nil {
+		http.Error(w, "invalid Range header", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	fetchStart := time.Now()
+	data, total, err := s.Storage.GetObjectRange(ctx, bucket, key, parsed.Start, parsed.End)
+	rl.FetchDuration = time.Since(fetchStart)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRangeOutOfBounds):
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", total))
+			http.Error(w, "range out of bounds", http.StatusRequestedRangeNotSatisfiable)
+		case errors.Is(err, ErrInvalidRangeLength):
+			http.Error(w, "invalid Range header", http.StatusRequestedRangeNotSatisfiable)
+		default:
+			var nfe *NotFoundError
+			if errors.As(err, &nfe) {
+				http.Error(w, "Image not found", http.StatusNotFound)
+				return
+			}
+			rl.Err = err
+			rl.emit()
+			s.Reproducer.Dump(rl.RequestID, r, nil)
+			http.Error(w, "Failed to fetch object range from storage", http.StatusBadGateway)
+		}
+		return
+	}
+
+	contentRange, length, err := contentRangeHeader(parsed, total)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", total))
+		http.Error(w, "range out of bounds", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	rl.SourceBytes = len(data)
+	rl.OutputFormat = contentType
+	rl.emit()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Range", contentRange)
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(data)
+}
+
+// parsePath splits a request path into a source name and object key; it
+// does not know about buckets or any other backend-specific concept.
+func parsePath(path string) (source, key string, err error) {
 	path = strings.TrimPrefix(path, "/")
 
 	idx := strings.Index(path, "/")
 	if idx == -1 {
-		return "", "", fmt.Errorf("path must be in format: /{bucket}/{key}")
+		return "", "", fmt.Errorf("path must be in format: /{source}/{key}")
 	}
 
-	bucket = path[:idx]
+	source = path[:idx]
 	key = path[idx+1:]
 
-	if bucket == "" {
-		return "", "", fmt.Errorf("bucket name is empty")
+	if source == "" {
+		return "", "", fmt.Errorf("source name is empty")
 	}
 	if key == "" {
 		return "", "", fmt.Errorf("object key is empty")
 	}
 
-	return bucket, key, nil
+	return source, key, nil
 }
 
-func determineFormat(accept string) OutputFormat {
-	if strings.Contains(accept, "image/webp") {
-		return OutputFormatWebP
+// resolveSource maps a path's source name to the backend-specific location
+// Storage should be called with. When Config.Sources is empty, the source
+// name is used verbatim as the location, so a bare /{bucket}/{key} URL
+// keeps working without configuring sources explicitly.
+func resolveSource(cfg *Config, source string) (string, error) {
+	if len(cfg.Sources) == 0 {
+		return source, nil
+	}
+	location, ok := cfg.Sources[source]
+	if !ok {
+		return "", fmt.Errorf("unknown source: %s", source)
 	}
-	return OutputFormatPNG
+	return location, nil
 }
+
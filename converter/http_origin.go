@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// HTTPStorage fetches objects from an upstream HTTP origin instead of an
+// object store, expanding {bucket} and {key} in OriginTemplate. It is
+// read-only: it does not implement ObjectPutter.
+type HTTPStorage struct {
+	OriginTemplate string
+	client         *http.Client
+}
+
+func NewHTTPStorage(cfg *Config) *HTTPStorage {
+	return &HTTPStorage{
+		OriginTemplate: cfg.HTTPOriginTemplate,
+		client:         &http.Client{},
+	}
+}
+
+func (h *HTTPStorage) url(bucket, key string) string {
+	u := strings.ReplaceAll(h.OriginTemplate, "{bucket}", escapePathSegments(bucket))
+	return strings.ReplaceAll(u, "{key}", escapePathSegments(key))
+}
+
+// escapePathSegments URL-escapes each "/"-separated segment of s
+// independently, preserving the separators a multi-segment key relies on
+// (e.g. "a/b.png") while making sure a segment containing "?", "#", or
+// other characters meaningful to URL parsing can't widen or redirect the
+// request OriginTemplate built once substituted in.
+func escapePathSegments(s string) string {
+	parts := strings.Split(s, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+func (h *HTTPStorage) GetObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url(bucket, key), nil)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP origin error: %w", err)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP origin error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP origin returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP origin error: %w", err)
+	}
+	return data, nil
+}
+
+func (h *HTTPStorage) GetObjectRange(ctx context.Context, bucket, key string, start, end int64) ([]byte, int64, error) {
+	rangeHeader, err := formatS3Range(start, end)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url(bucket, key), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("HTTP origin error: %w", err)
+	}
+	req.Header.Set("Range", rangeHeader)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("HTTP origin error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, 0, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// RFC 7233 servers send "Content-Range: bytes */<total>" on a 416;
+		// fall back to a HEAD request for origins that don't.
+		total, err := parseContentRangeTotal(resp.Header.Get("Content-Range"))
+		if err != nil {
+			if info, headErr := h.HeadObject(ctx, bucket, key); headErr == nil {
+				total = info.Size
+			}
+		}
+		return nil, total, ErrRangeOutOfBounds
+	case http.StatusPartialContent:
+		// handled below
+	default:
+		return nil, 0, fmt.Errorf("HTTP origin returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("HTTP origin error: %w", err)
+	}
+
+	total, err := parseContentRangeTotal(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, 0, fmt.Errorf("HTTP origin did not return a usable Content-Range: %w", err)
+	}
+
+	return data, total, nil
+}
+
+func (h *HTTPStorage) HeadObject(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, h.url(bucket, key), nil)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP origin error: %w", err)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP origin error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP origin returned status %d", resp.StatusCode)
+	}
+
+	size, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	return &ObjectInfo{
+		Size:        size,
+		ContentType: resp.Header.Get("Content-Type"),
+	}, nil
+}
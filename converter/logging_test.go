@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIDAcceptsValidHeader(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/bucket/key.png", nil)
+	r.Header.Set("X-Request-Id", "abc-123_XYZ")
+	if got := requestID(r); got != "abc-123_XYZ" {
+		t.Errorf("requestID() = %q, want caller-supplied value %q", got, "abc-123_XYZ")
+	}
+}
+
+func TestRequestIDRejectsUnsafeHeader(t *testing.T) {
+	unsafe := []string{
+		"../../etc/passwd",
+		"foo/bar",
+		"foo bar",
+		"foo\x00bar",
+	}
+	for _, id := range unsafe {
+		r := httptest.NewRequest(http.MethodGet, "/bucket/key.png", nil)
+		r.Header.Set("X-Request-Id", id)
+		if got := requestID(r); got == id {
+			t.Errorf("requestID() = %q, want a generated ID instead of the unsafe caller-supplied value", got)
+		}
+	}
+}
+
+func TestRequestIDGeneratesWhenHeaderAbsent(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/bucket/key.png", nil)
+	if got := requestID(r); got == "" || !validRequestID.MatchString(got) {
+		t.Errorf("requestID() = %q, want a non-empty generated ID matching validRequestID", got)
+	}
+}
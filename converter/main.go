@@ -6,9 +6,18 @@ import (
 	"log"
 	"net/http"
 	"os"
+
+	"github.com/windyakin/soeji/signer"
 )
 
 func main() {
+	// "soeji sign <url>" generates a signed URL and exits, bypassing the
+	// normal flag-based startup below.
+	if len(os.Args) > 1 && os.Args[1] == "sign" {
+		runSignCommand(os.Args[2:])
+		return
+	}
+
 	// Health check mode for Docker healthcheck
 	healthcheck := flag.Bool("healthcheck", false, "Run health check and exit")
 	flag.Parse()
@@ -29,18 +38,33 @@ func main() {
 	config := LoadConfig()
 
 	log.Printf("Starting soeji-converter on port %s", config.Port)
-	log.Printf("S3 endpoint: %s", config.S3Endpoint)
+	log.Printf("Storage backend: %s", config.StorageBackend)
 
-	// Initialize S3 client
-	s3Client, err := NewS3Client(config)
+	storage, err := newStorage(config)
 	if err != nil {
-		log.Fatalf("Failed to initialize S3 client: %v", err)
+		log.Fatalf("Failed to initialize storage backend: %v", err)
 	}
 
 	// Create app state
+	var cache *ImageCache
+	if config.CacheEnabled {
+		cache = NewImageCache(config)
+	}
+
+	var urlSigner *signer.Signer
+	if config.RequireSignedURLs {
+		if config.SigningSecret == "" {
+			log.Fatalf("REQUIRE_SIGNED_URLS is set but SIGNING_SECRET is empty")
+		}
+		urlSigner = signer.New(config.SigningSecret)
+	}
+
 	state := &AppState{
-		Config:   config,
-		S3Client: s3Client,
+		Config:     config,
+		Storage:    storage,
+		Cache:      cache,
+		Signer:     urlSigner,
+		Reproducer: NewRequestReproducer(config),
 	}
 
 	// Setup routes with a custom mux for proper routing
@@ -61,3 +85,46 @@ func main() {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
+
+// newStorage constructs the Storage backend selected by
+// Config.StorageBackend.
+func newStorage(cfg *Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "filesystem":
+		return NewFilesystemStorage(cfg), nil
+	case "webdav":
+		return NewWebDAVStorage(cfg), nil
+	case "http":
+		return NewHTTPStorage(cfg), nil
+	case "s3", "":
+		return NewS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend)
+	}
+}
+
+// runSignCommand implements "soeji sign [-ttl 1h] <url>", printing a signed
+// version of url using the server's configured SIGNING_SECRET.
+func runSignCommand(args []string) {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	ttl := fs.Duration("ttl", 0, "expiry duration for the signed URL (0 = no expiry)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: soeji sign [-ttl 1h] <url>")
+		os.Exit(1)
+	}
+
+	config := LoadConfig()
+	if config.SigningSecret == "" {
+		fmt.Fprintln(os.Stderr, "SIGNING_SECRET must be set to sign URLs")
+		os.Exit(1)
+	}
+
+	signedURL, err := signer.New(config.SigningSecret).SignURL(fs.Arg(0), *ttl)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to sign URL: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(signedURL)
+}
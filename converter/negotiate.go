@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// acceptedType is one media range parsed out of an Accept header, with its
+// q-value (defaulting to 1.0 when omitted).
+type acceptedType struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept parses an RFC 9110 Accept header into its media ranges, each
+// with its q-value. Ranges with q=0 (explicitly rejected) are dropped.
+// Malformed q-values fall back to 1.0 rather than rejecting the whole range.
+func parseAccept(accept string) []acceptedType {
+	if accept == "" {
+		return nil
+	}
+
+	var types []acceptedType
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			name, value, found := strings.Cut(param, "=")
+			if !found || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		types = append(types, acceptedType{mediaType: mediaType, q: q})
+	}
+	return types
+}
+
+// accepts reports whether mediaType (e.g. "image/avif") matches any of the
+// parsed ranges, returning the best (highest) q-value found. A range of
+// "image/*" or "*/*" matches any image/* mediaType.
+func accepts(types []acceptedType, mediaType string) (q float64, ok bool) {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	for _, t := range types {
+		switch t.mediaType {
+		case mediaType, "*/*", mainType + "/*":
+			if !ok || t.q > q {
+				q, ok = t.q, true
+			}
+		}
+	}
+	return q, ok
+}
+
+// determineFormat picks the best output format for an Accept header,
+// honoring both the client's q-values and the server's own ordering
+// (Config.PreferredFormats) as a tie-breaker between formats the client
+// accepts equally. Formats the client does not accept at all are never
+// chosen; if the header accepts nothing this service can produce (and does
+// not contain a wildcard), it falls back to PNG, the one format every
+// client can be assumed to render.
+func determineFormat(accept string, preferred []OutputFormat) OutputFormat {
+	types := parseAccept(accept)
+	if len(types) == 0 {
+		return OutputFormatPNG
+	}
+
+	best := OutputFormatPNG
+	bestQ := -1.0
+	for _, format := range preferred {
+		q, ok := accepts(types, outputContentType(format))
+		if !ok {
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	if bestQ < 0 {
+		return OutputFormatPNG
+	}
+	return best
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestParseAccept(t *testing.T) {
+	types := parseAccept("image/avif,image/webp;q=0.8,image/*;q=0.5,*/*;q=0.1")
+	if len(types) != 4 {
+		t.Fatalf("parseAccept() returned %d types, want 4", len(types))
+	}
+	if types[0].mediaType != "image/avif" || types[0].q != 1.0 {
+		t.Errorf("types[0] = %+v, want {image/avif 1.0}", types[0])
+	}
+	if types[1].mediaType != "image/webp" || types[1].q != 0.8 {
+		t.Errorf("types[1] = %+v, want {image/webp 0.8}", types[1])
+	}
+}
+
+func TestParseAcceptDropsZeroQValues(t *testing.T) {
+	types := parseAccept("image/avif;q=0,image/webp")
+	if len(types) != 1 || types[0].mediaType != "image/webp" {
+		t.Fatalf("parseAccept() = %+v, want only image/webp", types)
+	}
+}
+
+func TestParseAcceptMalformedQFallsBackToOne(t *testing.T) {
+	types := parseAccept("image/avif;q=not-a-number")
+	if len(types) != 1 || types[0].q != 1.0 {
+		t.Fatalf("parseAccept() = %+v, want q=1.0 fallback", types)
+	}
+}
+
+func TestParseAcceptEmpty(t *testing.T) {
+	if types := parseAccept(""); types != nil {
+		t.Fatalf("parseAccept(\"\") = %+v, want nil", types)
+	}
+}
+
+func TestAcceptsWildcards(t *testing.T) {
+	types := parseAccept("image/*;q=0.7")
+	q, ok := accepts(types, "image/avif")
+	if !ok || q != 0.7 {
+		t.Fatalf("accepts() = (%v, %v), want (0.7, true)", q, ok)
+	}
+	if _, ok := accepts(types, "video/mp4"); ok {
+		t.Fatal("accepts() matched video/mp4 against image/*")
+	}
+}
+
+func TestDetermineFormatHonorsPreferredOrderOnTie(t *testing.T) {
+	preferred := []OutputFormat{OutputFormatAVIF, OutputFormatWebP, OutputFormatJPEG, OutputFormatPNG}
+	got := determineFormat("image/avif,image/webp", preferred)
+	if got != OutputFormatAVIF {
+		t.Fatalf("determineFormat() = %v, want AVIF", got)
+	}
+}
+
+func TestDetermineFormatFallsBackToPNG(t *testing.T) {
+	preferred := []OutputFormat{OutputFormatAVIF, OutputFormatWebP, OutputFormatJPEG, OutputFormatPNG}
+	got := determineFormat("text/html", preferred)
+	if got != OutputFormatPNG {
+		t.Fatalf("determineFormat() = %v, want PNG", got)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// byteRange is a parsed HTTP Range request for a single range. Suffix ranges
+// ("bytes=-500") are represented with Start == -1 and End holding the
+// suffix length, matching the sentinel convention used by
+// S3Storage.GetObjectRange.
+type byteRange struct {
+	Start int64
+	End   int64
+}
+
+// parseRangeHeader parses a single-range "bytes=..." Range header value. Only
+// a single range is supported; multi-range requests are rejected as invalid
+// since this service has no use for a multipart/byteranges response.
+func parseRangeHeader(header string) (*byteRange, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return nil, ErrInvalidRangeLength
+	}
+	spec := strings.TrimPrefix(header, prefix)
+	if strings.Contains(spec, ",") {
+		return nil, ErrInvalidRangeLength
+	}
+
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidRangeLength
+	}
+
+	startStr, endStr := parts[0], parts[1]
+
+	if startStr == "" {
+		if endStr == "" {
+			return nil, ErrInvalidRangeLength
+		}
+		length, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || length <= 0 {
+			return nil, ErrInvalidRangeLength
+		}
+		return &byteRange{Start: -1, End: length}, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return nil, ErrInvalidRangeLength
+	}
+
+	if endStr == "" {
+		return &byteRange{Start: start, End: -1}, nil
+	}
+
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil || end < start {
+		return nil, ErrInvalidRangeLength
+	}
+	return &byteRange{Start: start, End: end}, nil
+}
+
+// contentTypeForKey maps an object key's extension to its content type,
+// purely to decide whether a Range request can be passed through without
+// decoding. It is deliberately small: the formats this service knows how to
+// produce, GIF, and the video containers Convert accepts as input.
+func contentTypeForKey(key string) string {
+	switch strings.ToLower(filepath.Ext(key)) {
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".webp":
+		return "image/webp"
+	case ".avif":
+		return "image/avif"
+	case ".gif":
+		return "image/gif"
+	case ".mp4":
+		return "video/mp4"
+	case ".webm":
+		return "video/webm"
+	case ".mov":
+		return "video/quicktime"
+	default:
+		return ""
+	}
+}
+
+// isContainerContentType reports whether contentType is one of the video
+// containers contentTypeForKey recognizes. OutputFormat has no "video"
+// member (Convert only ever produces image/* bytes), so passthroughEligible
+// checks this directly instead of comparing against outputContentType.
+func isContainerContentType(contentType string) bool {
+	switch contentType {
+	case "video/mp4", "video/webm", "video/quicktime":
+		return true
+	default:
+		return false
+	}
+}
+
+// outputContentType returns the MIME type ImageHandler would produce for the
+// given OutputFormat, used to check whether a conversion is actually a no-op
+// passthrough of the source bytes.
+func outputContentType(format OutputFormat) string {
+	switch format {
+	case OutputFormatPNG:
+		return "image/png"
+	case OutputFormatAVIF:
+		return "image/avif"
+	case OutputFormatWebP:
+		return "image/webp"
+	case OutputFormatJPEG:
+		return "image/jpeg"
+	default:
+		return ""
+	}
+}
+
+func contentRangeHeader(r *byteRange, total int64) (string, int64, error) {
+	start, end := r.Start, r.End
+	if start < 0 {
+		// Suffix range: last `end` bytes.
+		length := end
+		if length > total {
+			length = total
+		}
+		start = total - length
+		end = total - 1
+	} else if end < 0 || end >= total {
+		end = total - 1
+	}
+
+	if total == 0 || start >= total || start > end {
+		return "", 0, ErrRangeOutOfBounds
+	}
+
+	return fmt.Sprintf("bytes %d-%d/%d", start, end, total), end - start + 1, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestParseRangeHeader(t *testing.T) {
+	tests := []struct {
+		header    string
+		wantStart int64
+		wantEnd   int64
+	}{
+		{"bytes=0-499", 0, 499},
+		{"bytes=500-", 500, -1},
+		{"bytes=-500", -1, 500},
+	}
+	for _, tt := range tests {
+		r, err := parseRangeHeader(tt.header)
+		if err != nil {
+			t.Errorf("parseRangeHeader(%q) error = %v", tt.header, err)
+			continue
+		}
+		if r.Start != tt.wantStart || r.End != tt.wantEnd {
+			t.Errorf("parseRangeHeader(%q) = {%d, %d}, want {%d, %d}",
+				tt.header, r.Start, r.End, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+func TestParseRangeHeaderRejectsInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"bytes=",
+		"bytes=-",
+		"bytes=500-100",
+		"bytes=0-10,20-30",
+		"bad-unit=0-10",
+	}
+	for _, header := range invalid {
+		if _, err := parseRangeHeader(header); err == nil {
+			t.Errorf("parseRangeHeader(%q) error = nil, want error", header)
+		}
+	}
+}
+
+func TestContentRangeHeader(t *testing.T) {
+	cr, length, err := contentRangeHeader(&byteRange{Start: 0, End: 499}, 1000)
+	if err != nil {
+		t.Fatalf("contentRangeHeader() error = %v", err)
+	}
+	if cr != "bytes 0-499/1000" || length != 500 {
+		t.Fatalf("contentRangeHeader() = (%q, %d), want (\"bytes 0-499/1000\", 500)", cr, length)
+	}
+}
+
+func TestContentRangeHeaderSuffixRange(t *testing.T) {
+	cr, length, err := contentRangeHeader(&byteRange{Start: -1, End: 500}, 1000)
+	if err != nil {
+		t.Fatalf("contentRangeHeader() error = %v", err)
+	}
+	if cr != "bytes 500-999/1000" || length != 500 {
+		t.Fatalf("contentRangeHeader() = (%q, %d), want (\"bytes 500-999/1000\", 500)", cr, length)
+	}
+}
+
+func TestContentRangeHeaderOutOfBounds(t *testing.T) {
+	if _, _, err := contentRangeHeader(&byteRange{Start: 2000, End: 3000}, 1000); err != ErrRangeOutOfBounds {
+		t.Fatalf("contentRangeHeader() error = %v, want %v", err, ErrRangeOutOfBounds)
+	}
+}
+
+func TestIsContainerContentType(t *testing.T) {
+	for _, ct := range []string{"video/mp4", "video/webm", "video/quicktime"} {
+		if !isContainerContentType(ct) {
+			t.Errorf("isContainerContentType(%q) = false, want true", ct)
+		}
+	}
+	for _, ct := range []string{"image/png", "image/webp", ""} {
+		if isContainerContentType(ct) {
+			t.Errorf("isContainerContentType(%q) = true, want false", ct)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// RequestReproducer dumps failing requests to disk so they can be replayed
+// offline against Convert in tests, without needing production access.
+// Enabled by setting Config.RequestReproducerDir.
+type RequestReproducer struct {
+	Dir string
+}
+
+func NewRequestReproducer(cfg *Config) *RequestReproducer {
+	if cfg.RequestReproducerDir == "" {
+		return nil
+	}
+	return &RequestReproducer{Dir: cfg.RequestReproducerDir}
+}
+
+type reproducedRequest struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Query   map[string][]string `json:"query"`
+	Headers map[string][]string `json:"headers"`
+}
+
+// Dump writes the incoming HTTP request (headers + query) to
+// Dir/<requestID>.request.json, and the fetched source bytes (if any) to
+// Dir/<requestID>.source.
+func (p *RequestReproducer) Dump(requestID string, r *http.Request, source []byte) {
+	if p == nil {
+		return
+	}
+	if err := os.MkdirAll(p.Dir, 0o755); err != nil {
+		logger.Error("failed to create request reproducer directory", "error", err.Error())
+		return
+	}
+
+	req := reproducedRequest{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   map[string][]string(r.URL.Query()),
+		Headers: map[string][]string(r.Header),
+	}
+	if encoded, err := json.MarshalIndent(req, "", "  "); err == nil {
+		_ = os.WriteFile(filepath.Join(p.Dir, requestID+".request.json"), encoded, 0o644)
+	}
+	if source != nil {
+		_ = os.WriteFile(filepath.Join(p.Dir, requestID+".source"), source, 0o644)
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -13,11 +17,12 @@ import (
 	"github.com/aws/smithy-go"
 )
 
-type S3Client struct {
+// S3Storage implements Storage against an S3-compatible object store.
+type S3Storage struct {
 	client *s3.Client
 }
 
-func NewS3Client(cfg *Config) (*S3Client, error) {
+func NewS3Storage(cfg *Config) (*S3Storage, error) {
 	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
 		return aws.Endpoint{
 			URL:               cfg.S3Endpoint,
@@ -42,10 +47,11 @@ func NewS3Client(cfg *Config) (*S3Client, error) {
 		o.UsePathStyle = true
 	})
 
-	return &S3Client{client: client}, nil
+	return &S3Storage{client: client}, nil
 }
 
-func (s *S3Client) GetObject(ctx context.Context, bucket, key string) ([]byte, error) {
+func (s *S3Storage) GetObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	start := time.Now()
 	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
@@ -57,6 +63,8 @@ func (s *S3Client) GetObject(ctx context.Context, bucket, key string) ([]byte, e
 				return nil, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
 			}
 		}
+		logger.Error("s3 GetObject failed",
+			"request_id", requestIDFromContext(ctx), "bucket", bucket, "key", key, "error", err.Error())
 		return nil, fmt.Errorf("S3 error: %w", err)
 	}
 	defer result.Body.Close()
@@ -66,5 +74,126 @@ func (s *S3Client) GetObject(ctx context.Context, bucket, key string) ([]byte, e
 		return nil, fmt.Errorf("failed to read S3 object: %w", err)
 	}
 
+	logger.Debug("s3 GetObject",
+		"request_id", requestIDFromContext(ctx), "bucket", bucket, "key", key,
+		"bytes", len(data), "duration_ms", time.Since(start).Milliseconds())
+
 	return data, nil
 }
+
+// GetObjectRange fetches a byte range of an S3 object and returns the range
+// bytes along with the total size of the underlying object (parsed from the
+// response's Content-Range header).
+//
+// start/end follow S3's own Range semantics: start >= 0 && end >= 0 requests
+// an inclusive "start-end" range, start >= 0 && end < 0 requests "start-"
+// (from start to the end of the object), and start < 0 requests a suffix
+// range of the last `end` bytes ("-end").
+func (s *S3Storage) GetObjectRange(ctx context.Context, bucket, key string, start, end int64) ([]byte, int64, error) {
+	rangeHeader, err := formatS3Range(start, end)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(rangeHeader),
+	})
+	if err != nil {
+		var ae smithy.APIError
+		if errors.As(err, &ae) {
+			switch ae.ErrorCode() {
+			case "NoSuchKey":
+				return nil, 0, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
+			case "InvalidRange":
+				// The 416 we return needs the real object size in
+				// Content-Range, which GetObject's InvalidRange error
+				// doesn't carry; look it up with a HeadObject so the
+				// caller isn't stuck reporting "bytes */0".
+				total := int64(0)
+				if info, headErr := s.HeadObject(ctx, bucket, key); headErr == nil {
+					total = info.Size
+				}
+				return nil, total, ErrRangeOutOfBounds
+			}
+		}
+		return nil, 0, fmt.Errorf("S3 error: %w", err)
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read S3 object range: %w", err)
+	}
+
+	total, err := parseContentRangeTotal(aws.ToString(result.ContentRange))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to parse Content-Range: %w", err)
+	}
+
+	return data, total, nil
+}
+
+func formatS3Range(start, end int64) (string, error) {
+	switch {
+	case start >= 0 && end >= 0:
+		if end < start {
+			return "", ErrInvalidRangeLength
+		}
+		return fmt.Sprintf("bytes=%d-%d", start, end), nil
+	case start >= 0 && end < 0:
+		return fmt.Sprintf("bytes=%d-", start), nil
+	case start < 0 && end >= 0:
+		return fmt.Sprintf("bytes=-%d", end), nil
+	default:
+		return "", ErrInvalidRangeLength
+	}
+}
+
+// parseContentRangeTotal extracts the total object size from a
+// "bytes start-end/total" Content-Range header value.
+func parseContentRangeTotal(contentRange string) (int64, error) {
+	idx := strings.LastIndex(contentRange, "/")
+	if idx == -1 || idx+1 >= len(contentRange) {
+		return 0, fmt.Errorf("missing total in %q", contentRange)
+	}
+	total, err := strconv.ParseInt(contentRange[idx+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid total in %q: %w", contentRange, err)
+	}
+	return total, nil
+}
+
+func (s *S3Storage) HeadObject(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var ae smithy.APIError
+		if errors.As(err, &ae) {
+			if ae.ErrorCode() == "NotFound" || ae.ErrorCode() == "NoSuchKey" {
+				return nil, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
+			}
+		}
+		return nil, fmt.Errorf("S3 error: %w", err)
+	}
+
+	return &ObjectInfo{
+		Size:        aws.ToInt64(result.ContentLength),
+		ContentType: aws.ToString(result.ContentType),
+	}, nil
+}
+
+func (s *S3Storage) PutObject(ctx context.Context, bucket, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("S3 error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import "context"
+
+// ObjectInfo is the metadata a Storage backend can report about an object
+// without fetching its body.
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+// Storage abstracts the origin a converted object's bytes come from. The
+// first argument to every method is the backend-specific "location" a
+// source name resolves to (an S3 bucket, a filesystem subdirectory, a
+// WebDAV base path, ...) -- see resolveSource in handlers.go.
+type Storage interface {
+	GetObject(ctx context.Context, bucket, key string) ([]byte, error)
+	GetObjectRange(ctx context.Context, bucket, key string, start, end int64) (data []byte, total int64, err error)
+	HeadObject(ctx context.Context, bucket, key string) (*ObjectInfo, error)
+}
+
+// ObjectPutter is implemented by Storage backends that can also accept
+// writes (filesystem, WebDAV, S3). The read-only HTTP origin backend does
+// not implement it.
+type ObjectPutter interface {
+	PutObject(ctx context.Context, bucket, key string, data []byte) error
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/gif"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// InputKind classifies the source object fetched from S3 so callers (and
+// logging) can tell a plain image apart from an animated source or a video
+// that was reduced to a single frame.
+type InputKind int
+
+const (
+	InputKindImage InputKind = iota
+	InputKindAnimated
+	InputKindVideo
+)
+
+func (k InputKind) String() string {
+	switch k {
+	case InputKindAnimated:
+		return "animated"
+	case InputKindVideo:
+		return "video"
+	default:
+		return "image"
+	}
+}
+
+// detectInputKind sniffs the source bytes to decide how Convert should read
+// them. It looks past the generic MIME sniffing the stdlib offers, since
+// http.DetectContentType does not distinguish an animated GIF/WebP from a
+// static one, nor does it know about container formats like MP4/WebM/MOV.
+func detectInputKind(data []byte) InputKind {
+	switch {
+	case isVideoContainer(data):
+		return InputKindVideo
+	case isAnimatedGIF(data):
+		return InputKindAnimated
+	case isAnimatedWebP(data):
+		return InputKindAnimated
+	default:
+		return InputKindImage
+	}
+}
+
+func isVideoContainer(data []byte) bool {
+	if len(data) < 12 {
+		return false
+	}
+	// MP4/MOV: ISO base media file format, identified by an 'ftyp' box.
+	if bytes.Equal(data[4:8], []byte("ftyp")) {
+		return true
+	}
+	// WebM/MKV: EBML header.
+	if bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}) {
+		return true
+	}
+	return false
+}
+
+func isAnimatedGIF(data []byte) bool {
+	if !bytes.HasPrefix(data, []byte("GIF87a")) && !bytes.HasPrefix(data, []byte("GIF89a")) {
+		return false
+	}
+	cfg, err := gif.DecodeAll(bytes.NewReader(data))
+	return err == nil && len(cfg.Image) > 1
+}
+
+func isAnimatedWebP(data []byte) bool {
+	if len(data) < 16 || !bytes.Equal(data[0:4], []byte("RIFF")) || !bytes.Equal(data[8:12], []byte("WEBP")) {
+		return false
+	}
+	return bytes.Contains(data, []byte("ANIM"))
+}
+
+// extractGIFFrame decodes a single frame from an animated GIF. Frame
+// disposal/blending is intentionally ignored: callers get the raw frame
+// image as stored, which is sufficient for a "preview" use case.
+func extractGIFFrame(data []byte, index int) (image.Image, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode animated GIF: %w", err)
+	}
+	if index < 0 || index >= len(g.Image) {
+		return nil, fmt.Errorf("frame index %d out of range (0-%d)", index, len(g.Image)-1)
+	}
+	return g.Image[index], nil
+}
+
+// extractWebPFrame returns a single frame of an animated WebP. The WebP
+// encoder/decoder this service links against only exposes the first frame
+// of an animation, so any index other than 0 is rejected rather than
+// silently returning the wrong frame.
+func extractWebPFrame(data []byte, index int) (image.Image, error) {
+	if index != 0 {
+		return nil, fmt.Errorf("animated WebP frame extraction only supports frame 0 in this build")
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode animated WebP: %w", err)
+	}
+	return img, nil
+}
+
+// extractVideoFrame shells out to ffmpeg to render a single still frame from
+// a video container at the given timestamp, returning PNG-encoded bytes.
+// maxBytes rejects oversized sources before ffmpeg ever sees them (0
+// disables the check); timeout bounds how long ffmpeg may run (0 disables
+// the bound) and is wired to ctx so the request's own cancellation also
+// kills the subprocess.
+func extractVideoFrame(ctx context.Context, data []byte, at time.Duration, maxBytes int64, timeout time.Duration) ([]byte, error) {
+	if at < 0 {
+		at = 0
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("video source of %d bytes exceeds maximum of %d bytes", len(data), maxBytes)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	inFile, err := os.CreateTemp("", "soeji-video-in-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp input file: %w", err)
+	}
+	defer os.Remove(inFile.Name())
+	defer inFile.Close()
+
+	if _, err := inFile.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write temp input file: %w", err)
+	}
+	if err := inFile.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close temp input file: %w", err)
+	}
+
+	outFile, err := os.CreateTemp("", "soeji-video-out-*.png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp output file: %w", err)
+	}
+	defer os.Remove(outFile.Name())
+	outFile.Close()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-y",
+		"-ss", formatFFmpegTimestamp(at),
+		"-i", inFile.Name(),
+		"-frames:v", "1",
+		"-f", "image2",
+		"-vcodec", "png",
+		outFile.Name(),
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("ffmpeg frame extraction %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("ffmpeg frame extraction failed: %w: %s", err, stderr.String())
+	}
+
+	out, err := os.ReadFile(outFile.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read extracted video frame: %w", err)
+	}
+	return out, nil
+}
+
+func formatFFmpegTimestamp(at time.Duration) string {
+	total := at.Seconds()
+	hours := int(total) / 3600
+	minutes := (int(total) % 3600) / 60
+	seconds := total - float64(hours*3600+minutes*60)
+	return fmt.Sprintf("%02d:%02d:%09.6f", hours, minutes, seconds)
+}
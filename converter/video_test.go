@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func encodeGIF(t *testing.T, frames int) []byte {
+	t.Helper()
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		img := image.NewPaletted(image.Rect(0, 0, 4, 4), []color.Color{color.White, color.Black})
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, 0)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatalf("gif.EncodeAll() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectInputKindImage(t *testing.T) {
+	if kind := detectInputKind(encodeGIF(t, 1)); kind != InputKindImage {
+		t.Errorf("detectInputKind(single-frame GIF) = %v, want %v", kind, InputKindImage)
+	}
+}
+
+func TestDetectInputKindAnimatedGIF(t *testing.T) {
+	if kind := detectInputKind(encodeGIF(t, 3)); kind != InputKindAnimated {
+		t.Errorf("detectInputKind(multi-frame GIF) = %v, want %v", kind, InputKindAnimated)
+	}
+}
+
+func TestDetectInputKindVideo(t *testing.T) {
+	mp4 := []byte{0x00, 0x00, 0x00, 0x18, 'f', 't', 'y', 'p', 'i', 's', 'o', 'm'}
+	if kind := detectInputKind(mp4); kind != InputKindVideo {
+		t.Errorf("detectInputKind(mp4 ftyp box) = %v, want %v", kind, InputKindVideo)
+	}
+
+	webm := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	if kind := detectInputKind(webm); kind != InputKindVideo {
+		t.Errorf("detectInputKind(webm EBML header) = %v, want %v", kind, InputKindVideo)
+	}
+}
+
+func TestIsAnimatedGIF(t *testing.T) {
+	if isAnimatedGIF(encodeGIF(t, 1)) {
+		t.Error("isAnimatedGIF(single-frame GIF) = true, want false")
+	}
+	if !isAnimatedGIF(encodeGIF(t, 2)) {
+		t.Error("isAnimatedGIF(multi-frame GIF) = false, want true")
+	}
+	if isAnimatedGIF([]byte("not a gif")) {
+		t.Error("isAnimatedGIF(garbage) = true, want false")
+	}
+}
+
+func TestIsAnimatedWebP(t *testing.T) {
+	staticWebP := []byte("RIFF\x00\x00\x00\x00WEBPVP8 ")
+	if isAnimatedWebP(staticWebP) {
+		t.Error("isAnimatedWebP(static WebP) = true, want false")
+	}
+
+	animatedWebP := []byte("RIFF\x00\x00\x00\x00WEBPANIM")
+	if !isAnimatedWebP(animatedWebP) {
+		t.Error("isAnimatedWebP(ANIM chunk present) = false, want true")
+	}
+
+	if isAnimatedWebP([]byte("too short")) {
+		t.Error("isAnimatedWebP(too short) = true, want false")
+	}
+}
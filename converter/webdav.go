@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/studio-b12/gowebdav"
+)
+
+// WebDAVStorage serves objects from a WebDAV share. bucket is joined onto
+// BaseURL as a path prefix, mirroring how S3Storage treats bucket as a
+// namespace under the same endpoint.
+type WebDAVStorage struct {
+	client *gowebdav.Client
+}
+
+func NewWebDAVStorage(cfg *Config) *WebDAVStorage {
+	client := gowebdav.NewClient(cfg.WebDAVBaseURL, cfg.WebDAVUsername, cfg.WebDAVPassword)
+	return &WebDAVStorage{client: client}
+}
+
+func (w *WebDAVStorage) objectPath(bucket, key string) string {
+	return path.Join("/", bucket, key)
+}
+
+func (w *WebDAVStorage) GetObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	data, err := w.client.Read(w.objectPath(bucket, key))
+	if err != nil {
+		if gowebdav.IsErrNotFound(err) {
+			return nil, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
+		}
+		return nil, fmt.Errorf("WebDAV error: %w", err)
+	}
+	return data, nil
+}
+
+func (w *WebDAVStorage) GetObjectRange(ctx context.Context, bucket, key string, start, end int64) ([]byte, int64, error) {
+	info, err := w.client.Stat(w.objectPath(bucket, key))
+	if err != nil {
+		if gowebdav.IsErrNotFound(err) {
+			return nil, 0, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
+		}
+		return nil, 0, fmt.Errorf("WebDAV error: %w", err)
+	}
+	total := info.Size()
+
+	resolvedStart, resolvedEnd, err := resolveFileRange(start, end, total)
+	if err != nil {
+		return nil, total, err
+	}
+
+	reader, err := w.client.ReadStreamRange(w.objectPath(bucket, key), resolvedStart, resolvedEnd-resolvedStart+1)
+	if err != nil {
+		return nil, total, fmt.Errorf("WebDAV error: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, total, fmt.Errorf("WebDAV error: %w", err)
+	}
+	return data, total, nil
+}
+
+func (w *WebDAVStorage) HeadObject(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	info, err := w.client.Stat(w.objectPath(bucket, key))
+	if err != nil {
+		if gowebdav.IsErrNotFound(err) {
+			return nil, &NotFoundError{Path: fmt.Sprintf("%s/%s", bucket, key)}
+		}
+		return nil, fmt.Errorf("WebDAV error: %w", err)
+	}
+	return &ObjectInfo{Size: info.Size()}, nil
+}
+
+func (w *WebDAVStorage) PutObject(ctx context.Context, bucket, key string, data []byte) error {
+	if err := w.client.MkdirAll(path.Dir(w.objectPath(bucket, key)), 0o755); err != nil {
+		return fmt.Errorf("WebDAV error: %w", err)
+	}
+	if err := w.client.Write(w.objectPath(bucket, key), data, 0o644); err != nil {
+		return fmt.Errorf("WebDAV error: %w", err)
+	}
+	return nil
+}
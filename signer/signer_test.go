@@ -0,0 +1,104 @@
+package signer
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVerifyRoundTrip(t *testing.T) {
+	s := New("secret")
+	path := "/images/cat.png"
+	query := url.Values{"w": {"200"}, "fit": {"cover"}}
+
+	sig := s.Sign(path, query)
+	signed := url.Values{}
+	for k, v := range query {
+		signed[k] = v
+	}
+	signed.Set("sig", sig)
+
+	if err := s.Verify(path, signed); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+}
+
+func TestVerifyMissingSignature(t *testing.T) {
+	s := New("secret")
+	err := s.Verify("/images/cat.png", url.Values{"w": {"200"}})
+	if err != ErrMissingSignature {
+		t.Fatalf("Verify() = %v, want %v", err, ErrMissingSignature)
+	}
+}
+
+func TestVerifyRejectsTamperedQuery(t *testing.T) {
+	s := New("secret")
+	path := "/images/cat.png"
+	query := url.Values{"w": {"200"}}
+	sig := s.Sign(path, query)
+
+	tampered := url.Values{"w": {"9999"}, "sig": {sig}}
+	if err := s.Verify(path, tampered); err != ErrInvalidSignature {
+		t.Fatalf("Verify() = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestVerifyRejectsWrongSecret(t *testing.T) {
+	path := "/images/cat.png"
+	query := url.Values{"w": {"200"}}
+	sig := New("secret-a").Sign(path, query)
+
+	query.Set("sig", sig)
+	if err := New("secret-b").Verify(path, query); err != ErrInvalidSignature {
+		t.Fatalf("Verify() = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	s := New("secret")
+	path := "/images/cat.png"
+	query := url.Values{"exp": {"1"}} // 1970-01-01, long expired
+	sig := s.Sign(path, query)
+	query.Set("sig", sig)
+
+	if err := s.Verify(path, query); err != ErrExpired {
+		t.Fatalf("Verify() = %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestVerifyInvalidExpiry(t *testing.T) {
+	s := New("secret")
+	path := "/images/cat.png"
+	query := url.Values{"exp": {"not-a-number"}, "sig": {"whatever"}}
+
+	if err := s.Verify(path, query); err != ErrInvalidSignature {
+		t.Fatalf("Verify() = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestSignURLRoundTrip(t *testing.T) {
+	s := New("secret")
+	signed, err := s.SignURL("https://example.com/images/cat.png?w=200", time.Hour)
+	if err != nil {
+		t.Fatalf("SignURL() error = %v", err)
+	}
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+	if err := s.Verify(u.Path, u.Query()); err != nil {
+		t.Fatalf("Verify() on signed URL = %v, want nil", err)
+	}
+}
+
+func TestCanonicalizeIgnoresQueryOrder(t *testing.T) {
+	s := New("secret")
+	path := "/images/cat.png"
+	a := url.Values{"w": {"200"}, "h": {"100"}}
+	b := url.Values{"h": {"100"}, "w": {"200"}}
+
+	if s.Sign(path, a) != s.Sign(path, b) {
+		t.Fatal("Sign() depends on query parameter order")
+	}
+}